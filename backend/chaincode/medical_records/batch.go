@@ -0,0 +1,336 @@
+/*
+ * Hospital Management System - Medical Records Chaincode
+ * Merkle-root batch commitment mode for high-write workloads.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecordInput is one leaf of a batch submitted to AddRecordHashBatch.
+type RecordInput struct {
+	RecordID    string          `json:"recordId"`
+	PatientID   int             `json:"patientId"`
+	HashPayload interface{}     `json:"hashPayload"`
+	RecordType  string          `json:"recordType"`
+	CreatedBy   int             `json:"createdBy"`
+	Timestamp   string          `json:"timestamp"`
+	IPFS        *IPFSDescriptor `json:"ipfs,omitempty"`
+}
+
+// BatchCommit stores only the Merkle root of a batch plus per-leaf
+// metadata, so N records cost one PutState instead of 2*N.
+type BatchCommit struct {
+	BatchID string        `json:"batchId"`
+	Root    string        `json:"root"`
+	Leaves  []RecordInput `json:"leaves"`
+	TxID    string        `json:"txId"`
+}
+
+// MerkleProofStep is one step of an inclusion proof: the sibling hash and
+// whether it sits to the left of the running hash.
+type MerkleProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// BatchRecordEventPayload is the single chaincode event emitted for an
+// entire batch. Fabric only delivers the last SetEvent call made within a
+// transaction, so a batch can't emit one RECORD_ADDED event per leaf the
+// way AddRecordHash does for individual writes; instead it emits one
+// RECORD_BATCH_ADDED event carrying every leaf's summary, keeping the
+// audit-dashboard/HL7 event stream (see emitRecordEvent) alive for
+// batched writes too.
+type BatchRecordEventPayload struct {
+	EventName string               `json:"eventName"`
+	BatchID   string               `json:"batchId"`
+	TxID      string               `json:"txId"`
+	Records   []RecordEventPayload `json:"records"`
+}
+
+// leafHash returns the canonical-JSON SHA-256 hash of a RecordInput.
+func leafHash(record RecordInput) (string, error) {
+	canonicalJSON, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal record for hashing: %v", err)
+	}
+	sum := sha256.Sum256(canonicalJSON)
+	return fmt.Sprintf("%x", sum), nil
+}
+
+// pairHash hashes two sibling hashes together in left-right order.
+func pairHash(left, right string) string {
+	sum := sha256.Sum256([]byte(left + right))
+	return fmt.Sprintf("%x", sum)
+}
+
+// buildMerkleTree returns the full level-by-level tree for leafHashes,
+// duplicating the last leaf at each level when the level has an odd
+// count. Level 0 is the leaves; the last level is the single root.
+func buildMerkleTree(leafHashes []string) [][]string {
+	levels := [][]string{leafHashes}
+	current := leafHashes
+
+	for len(current) > 1 {
+		var next []string
+		for i := 0; i < len(current); i += 2 {
+			if i+1 < len(current) {
+				next = append(next, pairHash(current[i], current[i+1]))
+			} else {
+				next = append(next, pairHash(current[i], current[i]))
+			}
+		}
+		levels = append(levels, next)
+		current = next
+	}
+
+	return levels
+}
+
+// merkleProof returns the sibling path from leaf index to the root of the
+// given tree levels.
+func merkleProof(levels [][]string, index int) []MerkleProofStep {
+	var proof []MerkleProofStep
+
+	for level := 0; level < len(levels)-1; level++ {
+		nodes := levels[level]
+		isLeft := index%2 == 0
+		siblingIndex := index + 1
+		if isLeft && siblingIndex >= len(nodes) {
+			siblingIndex = index // odd level duplicates the last leaf
+		} else if !isLeft {
+			siblingIndex = index - 1
+		}
+
+		proof = append(proof, MerkleProofStep{
+			Hash: nodes[siblingIndex],
+			Left: !isLeft,
+		})
+
+		index = index / 2
+	}
+
+	return proof
+}
+
+// VerifyInclusionProof is a pure function so clients can verify a
+// record's inclusion in a batch without a Fabric connection.
+func VerifyInclusionProof(record RecordInput, proof []MerkleProofStep, root string) (bool, error) {
+	current, err := leafHash(record)
+	if err != nil {
+		return false, err
+	}
+
+	for _, step := range proof {
+		if step.Left {
+			current = pairHash(step.Hash, current)
+		} else {
+			current = pairHash(current, step.Hash)
+		}
+	}
+
+	return current == root, nil
+}
+
+// batchIndexKey is the key AddRecordHashBatch stores recordId -> batchId
+// pointers under, so individual lookups don't need to scan every batch.
+func batchIndexKey(recordId string) string {
+	return fmt.Sprintf("BATCHIDX_%s", recordId)
+}
+
+// AddRecordHashBatch groups N record inputs into a single BatchCommit,
+// validating and canonicalizing each the same way AddRecordHash does
+// (record type, IPFS CID), and storing only the Merkle root and per-leaf
+// metadata rather than two PutState calls per record. It also writes one
+// small recordId -> batchId pointer per leaf so individual lookups stay
+// O(1) instead of scanning every batch; that gives up part of the write
+// savings (N+1 PutState calls instead of 1) in exchange for not paying an
+// O(total batches) scan on every read.
+func (c *MedicalRecordsContract) AddRecordHashBatch(
+	ctx contractapi.TransactionContextInterface,
+	records []RecordInput,
+) (string, error) {
+	if len(records) == 0 {
+		return "", fmt.Errorf("batch must contain at least one record")
+	}
+
+	for i, record := range records {
+		if err := validateRecordType(record.RecordType); err != nil {
+			return "", err
+		}
+
+		payload, descriptor, err := canonicalizeIPFSPayload(record.HashPayload)
+		if err != nil {
+			return "", err
+		}
+		records[i].HashPayload = payload
+		records[i].IPFS = descriptor
+	}
+
+	leafHashes := make([]string, len(records))
+	for i, record := range records {
+		hash, err := leafHash(record)
+		if err != nil {
+			return "", err
+		}
+		leafHashes[i] = hash
+	}
+
+	levels := buildMerkleTree(leafHashes)
+	root := levels[len(levels)-1][0]
+
+	txID := ctx.GetStub().GetTxID()
+	batchId := txID
+
+	batch := BatchCommit{
+		BatchID: batchId,
+		Root:    root,
+		Leaves:  records,
+		TxID:    txID,
+	}
+
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal batch commit: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(fmt.Sprintf("BATCH_%s", batchId), batchJSON); err != nil {
+		return "", fmt.Errorf("failed to put batch commit state: %v", err)
+	}
+
+	for _, record := range records {
+		if err := ctx.GetStub().PutState(batchIndexKey(record.RecordID), []byte(batchId)); err != nil {
+			return "", fmt.Errorf("failed to put batch index state: %v", err)
+		}
+	}
+
+	if err := emitBatchRecordEvent(ctx, batchId, txID, records); err != nil {
+		return "", err
+	}
+
+	return batchId, nil
+}
+
+// emitBatchRecordEvent sets a single RECORD_BATCH_ADDED event summarizing
+// every leaf in the batch (see BatchRecordEventPayload for why this can't
+// be one event per leaf).
+func emitBatchRecordEvent(ctx contractapi.TransactionContextInterface, batchId string, txID string, records []RecordInput) error {
+	recordEvents := make([]RecordEventPayload, len(records))
+	for i, record := range records {
+		recordEvents[i] = RecordEventPayload{
+			EventName:  fmt.Sprintf("RECORD_ADDED.%s", record.RecordType),
+			RecordID:   record.RecordID,
+			PatientID:  record.PatientID,
+			RecordType: record.RecordType,
+			CreatedBy:  record.CreatedBy,
+			TxID:       txID,
+			Timestamp:  record.Timestamp,
+			Hash:       recordHashSummary(record.HashPayload),
+		}
+	}
+
+	payload := BatchRecordEventPayload{
+		EventName: "RECORD_BATCH_ADDED",
+		BatchID:   batchId,
+		TxID:      txID,
+		Records:   recordEvents,
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch event payload: %v", err)
+	}
+
+	if err := ctx.GetStub().SetEvent(payload.EventName, payloadJSON); err != nil {
+		return fmt.Errorf("failed to set batch event: %v", err)
+	}
+
+	return nil
+}
+
+// GetBatchRoot returns the Merkle root of a stored batch, for anchoring
+// to an external chain.
+func (c *MedicalRecordsContract) GetBatchRoot(
+	ctx contractapi.TransactionContextInterface,
+	batchId string,
+) (string, error) {
+	batch, err := getBatchCommit(ctx, batchId)
+	if err != nil {
+		return "", err
+	}
+	return batch.Root, nil
+}
+
+// getBatchCommit loads and unmarshals a BatchCommit by ID.
+func getBatchCommit(
+	ctx contractapi.TransactionContextInterface,
+	batchId string,
+) (*BatchCommit, error) {
+	batchJSON, err := ctx.GetStub().GetState(fmt.Sprintf("BATCH_%s", batchId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch commit: %v", err)
+	}
+	if batchJSON == nil {
+		return nil, fmt.Errorf("batch not found: %s", batchId)
+	}
+
+	var batch BatchCommit
+	if err := json.Unmarshal(batchJSON, &batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch commit: %v", err)
+	}
+
+	return &batch, nil
+}
+
+// findRecordInBatches resolves recordId to its batch via the
+// recordId -> batchId pointer AddRecordHashBatch writes, then recomputes
+// its sibling path. O(1) state reads instead of scanning every batch.
+func findRecordInBatches(ctx contractapi.TransactionContextInterface, recordId string) (*RecordInput, []MerkleProofStep, error) {
+	batchIdBytes, err := ctx.GetStub().GetState(batchIndexKey(recordId))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read batch index: %v", err)
+	}
+	if batchIdBytes == nil {
+		return nil, nil, fmt.Errorf("record %s not found in any batch", recordId)
+	}
+
+	batch, err := getBatchCommit(ctx, string(batchIdBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return GetRecordFromBatch(batch, recordId)
+}
+
+// GetRecordFromBatch derives a record and its inclusion proof from a
+// stored batch by recomputing the sibling path, so individual lookups
+// don't require a separate PutState per record.
+func GetRecordFromBatch(batch *BatchCommit, recordId string) (*RecordInput, []MerkleProofStep, error) {
+	index := -1
+	leafHashes := make([]string, len(batch.Leaves))
+	for i, leaf := range batch.Leaves {
+		hash, err := leafHash(leaf)
+		if err != nil {
+			return nil, nil, err
+		}
+		leafHashes[i] = hash
+		if leaf.RecordID == recordId {
+			index = i
+		}
+	}
+
+	if index == -1 {
+		return nil, nil, fmt.Errorf("record %s not found in batch %s", recordId, batch.BatchID)
+	}
+
+	levels := buildMerkleTree(leafHashes)
+	proof := merkleProof(levels, index)
+
+	return &batch.Leaves[index], proof, nil
+}
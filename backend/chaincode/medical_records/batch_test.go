@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func sampleRecords(n int) []RecordInput {
+	records := make([]RecordInput, n)
+	for i := 0; i < n; i++ {
+		records[i] = RecordInput{
+			RecordID:    fmt.Sprintf("VISIT_%d", i),
+			PatientID:   i % 10,
+			HashPayload: HashPayloadSimple{Hash: fmt.Sprintf("hash-%d", i)},
+			RecordType:  "VISIT",
+			CreatedBy:   1,
+			Timestamp:   "2026-07-27T00:00:00Z",
+		}
+	}
+	return records
+}
+
+func TestBuildMerkleTreeAndInclusionProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 8, 101} {
+		records := sampleRecords(n)
+
+		leafHashes := make([]string, n)
+		for i, record := range records {
+			hash, err := leafHash(record)
+			if err != nil {
+				t.Fatalf("leafHash(%d): %v", i, err)
+			}
+			leafHashes[i] = hash
+		}
+
+		levels := buildMerkleTree(leafHashes)
+		root := levels[len(levels)-1][0]
+
+		for i, record := range records {
+			proof := merkleProof(levels, i)
+			ok, err := VerifyInclusionProof(record, proof, root)
+			if err != nil {
+				t.Fatalf("n=%d leaf=%d: VerifyInclusionProof error: %v", n, i, err)
+			}
+			if !ok {
+				t.Fatalf("n=%d leaf=%d: inclusion proof did not verify against the root", n, i)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionProofRejectsTamperedRecord(t *testing.T) {
+	records := sampleRecords(5)
+
+	leafHashes := make([]string, len(records))
+	for i, record := range records {
+		hash, err := leafHash(record)
+		if err != nil {
+			t.Fatalf("leafHash: %v", err)
+		}
+		leafHashes[i] = hash
+	}
+
+	levels := buildMerkleTree(leafHashes)
+	root := levels[len(levels)-1][0]
+	proof := merkleProof(levels, 2)
+
+	tampered := records[2]
+	tampered.HashPayload = HashPayloadSimple{Hash: "tampered"}
+
+	ok, err := VerifyInclusionProof(tampered, proof, root)
+	if err != nil {
+		t.Fatalf("VerifyInclusionProof error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected inclusion proof to fail for a tampered record")
+	}
+}
+
+func TestGetRecordFromBatch(t *testing.T) {
+	records := sampleRecords(9)
+
+	leafHashes := make([]string, len(records))
+	for i, record := range records {
+		hash, err := leafHash(record)
+		if err != nil {
+			t.Fatalf("leafHash: %v", err)
+		}
+		leafHashes[i] = hash
+	}
+	root := buildMerkleTree(leafHashes)[len(buildMerkleTree(leafHashes))-1][0]
+
+	batch := &BatchCommit{BatchID: "batch-1", Root: root, Leaves: records, TxID: "tx-1"}
+
+	input, proof, err := GetRecordFromBatch(batch, "VISIT_5")
+	if err != nil {
+		t.Fatalf("GetRecordFromBatch: %v", err)
+	}
+	if input.RecordID != "VISIT_5" {
+		t.Fatalf("expected VISIT_5, got %s", input.RecordID)
+	}
+
+	ok, err := VerifyInclusionProof(*input, proof, root)
+	if err != nil {
+		t.Fatalf("VerifyInclusionProof: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected derived record to verify against the batch root")
+	}
+
+	if _, _, err := GetRecordFromBatch(batch, "VISIT_does_not_exist"); err == nil {
+		t.Fatal("expected an error for a record not present in the batch")
+	}
+}
+
+// BenchmarkBuildMerkleTreeBatch demonstrates the write-amplification
+// reduction AddRecordHashBatch targets: building (and, per read, deriving
+// an inclusion proof from) a 100+ record batch costs one Merkle root
+// computation instead of the 2*N PutState calls AddRecordHash makes per
+// record when writing the same records individually.
+func BenchmarkBuildMerkleTreeBatch(b *testing.B) {
+	records := sampleRecords(200)
+	leafHashes := make([]string, len(records))
+	for i, record := range records {
+		hash, err := leafHash(record)
+		if err != nil {
+			b.Fatalf("leafHash: %v", err)
+		}
+		leafHashes[i] = hash
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buildMerkleTree(leafHashes)
+	}
+}
@@ -0,0 +1,311 @@
+/*
+ * Hospital Management System - Medical Records Chaincode
+ * Hash-time-locked consent for third-party access to patient records.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// implicitOrgCollection returns the name of the Fabric-managed implicit
+// private data collection scoped to a single org's peers. Chaincode events
+// are broadcast to every peer/client subscribed to this channel's events,
+// so the secret the off-chain gateway needs to decrypt PHI must never be
+// put in one; writing it to the claiming grantee's own implicit collection
+// instead means only that grantee's org can read it back, with no shared
+// explicit collection (and no collections_config.json entry) for other
+// orgs to read from.
+func implicitOrgCollection(mspID string) string {
+	return fmt.Sprintf("_implicit_org_%s", mspID)
+}
+
+// ConsentLock represents a hash-time-locked grant of access to a patient
+// record, modeled on the commit/claim/cancel clauses of an HTLC atomic
+// swap: the grantee can only claim access by revealing a preimage whose
+// SHA-256 hash matches HashOfPreimage, and only before ExpiryTimestamp;
+// after expiry only the original sender can cancel it.
+type ConsentLock struct {
+	RecordID        string `json:"recordId"`
+	RecordType      string `json:"recordType"`
+	GranteeID       string `json:"granteeId"`
+	Sender          string `json:"sender"`
+	HashOfPreimage  string `json:"hashOfPreimage"`
+	ExpiryTimestamp int64  `json:"expiryTimestamp"` // Unix seconds, compared against GetTxTimestamp()
+	Claimed         bool   `json:"claimed"`
+	Canceled        bool   `json:"canceled"`
+	TxID            string `json:"txId"`
+}
+
+// AccessGrantedEvent is the public chaincode event emitted once access
+// has been claimed. It intentionally carries no secret material: the
+// preimage itself is delivered only to the grantee's own org, via that
+// org's implicit private data collection (see implicitOrgCollection).
+// This event just tells any channel observer that a claim happened.
+type AccessGrantedEvent struct {
+	EventName  string `json:"eventName"`
+	RecordID   string `json:"recordId"`
+	RecordType string `json:"recordType"`
+	GranteeID  string `json:"granteeId"`
+	TxID       string `json:"txId"`
+}
+
+// consentLockKey builds the composite key a ConsentLock is stored under,
+// keyed by (recordId, granteeID) so a patient can grant the same record
+// to multiple grantees independently.
+func consentLockKey(ctx contractapi.TransactionContextInterface, recordId string, granteeID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("CONSENT", []string{recordId, granteeID})
+}
+
+// preimageMatchesCommitment reports whether sha256(preimage) equals the
+// hex-encoded hashOfPreimage commitment.
+func preimageMatchesCommitment(preimage string, hashOfPreimage string) bool {
+	sum := sha256.Sum256([]byte(preimage))
+	return hex.EncodeToString(sum[:]) == hashOfPreimage
+}
+
+// LockRecordAccess writes a ConsentLock committing a patient (the calling
+// client identity) to grant granteeID access to recordId once they reveal
+// a preimage hashing to hashOfPreimage, valid until expiryTimestamp (Unix
+// seconds).
+func (c *MedicalRecordsContract) LockRecordAccess(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	recordType string,
+	granteeID string,
+	hashOfPreimage string,
+	expiryTimestamp int64,
+) error {
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	key, err := consentLockKey(ctx, recordId, granteeID)
+	if err != nil {
+		return fmt.Errorf("failed to create consent lock key: %v", err)
+	}
+
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read existing consent lock: %v", err)
+	}
+	if existingJSON != nil {
+		var existing ConsentLock
+		if err := json.Unmarshal(existingJSON, &existing); err == nil && !existing.Claimed && !existing.Canceled {
+			return fmt.Errorf("active consent lock already exists for record %s and grantee %s", recordId, granteeID)
+		}
+	}
+
+	lock := ConsentLock{
+		RecordID:        recordId,
+		RecordType:      recordType,
+		GranteeID:       granteeID,
+		Sender:          sender,
+		HashOfPreimage:  hashOfPreimage,
+		ExpiryTimestamp: expiryTimestamp,
+		TxID:            ctx.GetStub().GetTxID(),
+	}
+
+	lockJSON, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consent lock: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(key, lockJSON); err != nil {
+		return fmt.Errorf("failed to put consent lock state: %v", err)
+	}
+
+	return nil
+}
+
+// ClaimRecordAccess verifies that sha256(preimage) == hashOfPreimage and
+// that the lock has not yet expired (per ctx.GetStub().GetTxTimestamp()),
+// then marks the lock claimed, writes the preimage to the claiming
+// grantee's own implicit private data collection, and emits a secret-free
+// ACCESS_GRANTED event.
+func (c *MedicalRecordsContract) ClaimRecordAccess(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	recordType string,
+	granteeID string,
+	preimage string,
+) error {
+	key, err := consentLockKey(ctx, recordId, granteeID)
+	if err != nil {
+		return fmt.Errorf("failed to create consent lock key: %v", err)
+	}
+
+	lockJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read consent lock: %v", err)
+	}
+	if lockJSON == nil {
+		return fmt.Errorf("no consent lock found for record %s and grantee %s", recordId, granteeID)
+	}
+
+	var lock ConsentLock
+	if err := json.Unmarshal(lockJSON, &lock); err != nil {
+		return fmt.Errorf("failed to unmarshal consent lock: %v", err)
+	}
+
+	if lock.Claimed {
+		return fmt.Errorf("consent lock already claimed")
+	}
+	if lock.Canceled {
+		return fmt.Errorf("consent lock was canceled")
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get tx timestamp: %v", err)
+	}
+	if txTimestamp.Seconds >= lock.ExpiryTimestamp {
+		return fmt.Errorf("consent lock expired at %d", lock.ExpiryTimestamp)
+	}
+
+	if !preimageMatchesCommitment(preimage, lock.HashOfPreimage) {
+		return fmt.Errorf("preimage does not match hash commitment")
+	}
+
+	lock.Claimed = true
+	lockJSON, err = json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consent lock: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, lockJSON); err != nil {
+		return fmt.Errorf("failed to put consent lock state: %v", err)
+	}
+
+	granteeMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	preimageKey, err := ctx.GetStub().CreateCompositeKey("CONSENT_PREIMAGE", []string{recordId, granteeID})
+	if err != nil {
+		return fmt.Errorf("failed to create preimage key: %v", err)
+	}
+	if err := ctx.GetStub().PutPrivateData(implicitOrgCollection(granteeMSPID), preimageKey, []byte(preimage)); err != nil {
+		return fmt.Errorf("failed to store preimage privately: %v", err)
+	}
+
+	event := AccessGrantedEvent{
+		EventName:  "ACCESS_GRANTED",
+		RecordID:   recordId,
+		RecordType: recordType,
+		GranteeID:  granteeID,
+		TxID:       ctx.GetStub().GetTxID(),
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access granted event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(event.EventName, eventJSON); err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// CancelRecordAccess lets the original sender reclaim an expired, unclaimed
+// consent lock. Symmetric with ClaimRecordAccess: only callable by the
+// patient who created the lock, and only after expiry.
+func (c *MedicalRecordsContract) CancelRecordAccess(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	granteeID string,
+) error {
+	sender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	key, err := consentLockKey(ctx, recordId, granteeID)
+	if err != nil {
+		return fmt.Errorf("failed to create consent lock key: %v", err)
+	}
+
+	lockJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read consent lock: %v", err)
+	}
+	if lockJSON == nil {
+		return fmt.Errorf("no consent lock found for record %s and grantee %s", recordId, granteeID)
+	}
+
+	var lock ConsentLock
+	if err := json.Unmarshal(lockJSON, &lock); err != nil {
+		return fmt.Errorf("failed to unmarshal consent lock: %v", err)
+	}
+
+	if lock.Sender != sender {
+		return fmt.Errorf("only the original sender may cancel this consent lock")
+	}
+	if lock.Claimed {
+		return fmt.Errorf("consent lock already claimed")
+	}
+	if lock.Canceled {
+		return fmt.Errorf("consent lock already canceled")
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get tx timestamp: %v", err)
+	}
+	if txTimestamp.Seconds < lock.ExpiryTimestamp {
+		return fmt.Errorf("consent lock has not yet expired")
+	}
+
+	lock.Canceled = true
+	lockJSON, err = json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consent lock: %v", err)
+	}
+
+	return ctx.GetStub().PutState(key, lockJSON)
+}
+
+// GetActiveConsents returns every unclaimed, uncanceled ConsentLock for
+// the given patient's records.
+func (c *MedicalRecordsContract) GetActiveConsents(
+	ctx contractapi.TransactionContextInterface,
+	patientId int,
+) ([]*ConsentLock, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("CONSENT", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consent locks: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var locks []*ConsentLock
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate consent locks: %v", err)
+		}
+
+		var lock ConsentLock
+		if err := json.Unmarshal(queryResponse.Value, &lock); err != nil {
+			continue
+		}
+		if lock.Claimed || lock.Canceled {
+			continue
+		}
+
+		record, err := c.GetRecordHash(ctx, lock.RecordID, lock.RecordType)
+		if err != nil || record.PatientID != patientId {
+			continue
+		}
+
+		locks = append(locks, &lock)
+	}
+
+	return locks, nil
+}
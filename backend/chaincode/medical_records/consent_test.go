@@ -0,0 +1,20 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPreimageMatchesCommitment(t *testing.T) {
+	preimage := "open-sesame"
+	sum := sha256.Sum256([]byte(preimage))
+	commitment := hex.EncodeToString(sum[:])
+
+	if !preimageMatchesCommitment(preimage, commitment) {
+		t.Fatal("expected the correct preimage to match its commitment")
+	}
+	if preimageMatchesCommitment("wrong-secret", commitment) {
+		t.Fatal("expected an incorrect preimage to be rejected")
+	}
+}
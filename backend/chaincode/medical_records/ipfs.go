@@ -0,0 +1,134 @@
+/*
+ * Hospital Management System - Medical Records Chaincode
+ * IPFS content identifier validation and tamper detection.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// IPFSDescriptor records the parsed form of an IPFS CID alongside the
+// canonical CIDv1 base32 string, so VerifyIPFSContent can recompute a
+// file's multihash without trusting whichever gateway served it.
+type IPFSDescriptor struct {
+	CID          string `json:"cid"`          // canonical CIDv1 base32 string
+	Codec        uint64 `json:"codec"`        // multicodec, e.g. dag-pb or raw
+	HashFunction uint64 `json:"hashFunction"` // multihash function code
+	DigestLength int    `json:"digestLength"` // multihash digest length in bytes
+}
+
+// ParseAndCanonicalizeCID decodes rawCID with the multiformats stack,
+// rejecting anything that doesn't round-trip, and returns an
+// IPFSDescriptor plus the canonical CIDv1 base32 representation.
+func ParseAndCanonicalizeCID(rawCID string) (*IPFSDescriptor, string, error) {
+	parsed, err := cid.Decode(rawCID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode CID: %v", err)
+	}
+
+	decodedHash, err := mh.Decode(parsed.Hash())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode multihash: %v", err)
+	}
+
+	canonical := cid.NewCidV1(parsed.Type(), parsed.Hash())
+	canonicalStr, err := canonical.StringOfBase(multibase.Base32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode canonical CID: %v", err)
+	}
+
+	// Round-trip check: re-decoding the canonical form must reproduce the
+	// same multihash digest we started from.
+	roundTripped, err := cid.Decode(canonicalStr)
+	if err != nil || !bytes.Equal(roundTripped.Hash(), parsed.Hash()) {
+		return nil, "", fmt.Errorf("CID did not round-trip: %s", rawCID)
+	}
+
+	descriptor := &IPFSDescriptor{
+		CID:          canonicalStr,
+		Codec:        uint64(parsed.Type()),
+		HashFunction: uint64(decodedHash.Code),
+		DigestLength: len(decodedHash.Digest),
+	}
+
+	return descriptor, canonicalStr, nil
+}
+
+// canonicalizeIPFSPayload inspects a parsed hash payload for an embedded
+// IPFS CID (via HashPayloadComplex.IPFSHash), validating and canonicalizing
+// it in place so the same content never produces two distinct keys. If the
+// payload carries no IPFS hash, it is returned unchanged with a nil
+// descriptor. Shared by AddRecordHash, UpdateRecordHash and
+// AddRecordHashBatch so none of them can silently drop a record's
+// IPFSDescriptor.
+func canonicalizeIPFSPayload(payload interface{}) (interface{}, *IPFSDescriptor, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal hash payload: %v", err)
+	}
+
+	var complexPayload HashPayloadComplex
+	if err := json.Unmarshal(payloadJSON, &complexPayload); err != nil || complexPayload.IPFSHash == "" {
+		return payload, nil, nil
+	}
+
+	descriptor, canonicalCID, err := ParseAndCanonicalizeCID(complexPayload.IPFSHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid IPFS CID: %v", err)
+	}
+
+	complexPayload.IPFSHash = canonicalCID
+	return complexPayload, descriptor, nil
+}
+
+// VerifyIPFSContent recomputes the multihash of rawBytes using the
+// algorithm recorded in the record's IPFSDescriptor and compares digests,
+// detecting tampering in files fetched from any IPFS gateway without
+// trusting that gateway.
+func (c *MedicalRecordsContract) VerifyIPFSContent(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	recordType string,
+	rawBytes []byte,
+) (bool, error) {
+	record, err := c.GetRecordHash(ctx, recordId, recordType)
+	if err != nil {
+		return false, err
+	}
+
+	if record.IPFS == nil {
+		return false, fmt.Errorf("record has no IPFS descriptor: %s_%s", recordType, recordId)
+	}
+
+	digest, err := mh.Sum(rawBytes, record.IPFS.HashFunction, record.IPFS.DigestLength)
+	if err != nil {
+		return false, fmt.Errorf("failed to compute multihash: %v", err)
+	}
+
+	decoded, err := mh.Decode(digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode computed multihash: %v", err)
+	}
+
+	stored, err := cid.Decode(record.IPFS.CID)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode stored CID: %v", err)
+	}
+
+	storedHash, err := mh.Decode(stored.Hash())
+	if err != nil {
+		return false, fmt.Errorf("failed to decode stored multihash: %v", err)
+	}
+
+	return bytes.Equal(decoded.Digest, storedHash.Digest), nil
+}
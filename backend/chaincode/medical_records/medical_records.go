@@ -23,13 +23,14 @@ type MedicalRecordsContract struct {
 
 // RecordHash represents a hash record stored on the blockchain
 type RecordHash struct {
-	RecordID    string      `json:"recordId"`
-	PatientID   int         `json:"patientId"`
-	HashPayload interface{} `json:"hashPayload"` // Can be string or HashPayloadComplex
-	RecordType  string      `json:"recordType"`  // PATIENT, VISIT, PRESCRIPTION, REPORT, BILLING, APPOINTMENT
-	CreatedBy   int         `json:"createdBy"`
-	Timestamp   string      `json:"timestamp"`
-	TxID        string      `json:"txId"`
+	RecordID    string          `json:"recordId"`
+	PatientID   int             `json:"patientId"`
+	HashPayload interface{}     `json:"hashPayload"` // Can be string or HashPayloadComplex
+	RecordType  string          `json:"recordType"`  // PATIENT, VISIT, PRESCRIPTION, REPORT, BILLING, APPOINTMENT
+	CreatedBy   int             `json:"createdBy"`
+	Timestamp   string          `json:"timestamp"`
+	TxID        string          `json:"txId"`
+	IPFS        *IPFSDescriptor `json:"ipfs,omitempty"`
 }
 
 // HashPayloadSimple for form-only records
@@ -51,6 +52,88 @@ type RecordHistory struct {
 	Record    *RecordHash `json:"record"`
 }
 
+// RecordEventPayload is the structured, versioned body emitted on every
+// hash mutation so off-chain services can subscribe without polling
+// GetRecordHistory.
+type RecordEventPayload struct {
+	EventName  string `json:"eventName"`
+	RecordID   string `json:"recordId"`
+	PatientID  int    `json:"patientId"`
+	RecordType string `json:"recordType"`
+	CreatedBy  int    `json:"createdBy"`
+	TxID       string `json:"txId"`
+	Timestamp  string `json:"timestamp"`
+	Hash       string `json:"hash,omitempty"`
+}
+
+// emitRecordEvent builds a RECORD_<action>.<recordType> event name (e.g.
+// RECORD_ADDED.PRESCRIPTION) so downstream consumers can filter by record
+// type using Fabric's event topic matching, and sets it on the stub.
+func emitRecordEvent(ctx contractapi.TransactionContextInterface, action string, record *RecordHash) error {
+	payload := RecordEventPayload{
+		EventName:  fmt.Sprintf("RECORD_%s.%s", action, record.RecordType),
+		RecordID:   record.RecordID,
+		PatientID:  record.PatientID,
+		RecordType: record.RecordType,
+		CreatedBy:  record.CreatedBy,
+		TxID:       record.TxID,
+		Timestamp:  record.Timestamp,
+		Hash:       recordHashSummary(record.HashPayload),
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %v", err)
+	}
+
+	if err := ctx.GetStub().SetEvent(payload.EventName, payloadJSON); err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
+
+// recordHashSummary best-effort extracts a single representative hash
+// string from a HashPayload for inclusion in event payloads.
+func recordHashSummary(hashPayload interface{}) string {
+	payloadJSON, err := json.Marshal(hashPayload)
+	if err != nil {
+		return ""
+	}
+
+	var simplePayload HashPayloadSimple
+	if err := json.Unmarshal(payloadJSON, &simplePayload); err == nil && simplePayload.Hash != "" {
+		return simplePayload.Hash
+	}
+
+	var complexPayload HashPayloadComplex
+	if err := json.Unmarshal(payloadJSON, &complexPayload); err == nil {
+		return complexPayload.FormHash
+	}
+
+	return ""
+}
+
+// validRecordTypes enumerates the record types accepted by AddRecordHash,
+// UpdateRecordHash and AddRecordHashBatch.
+var validRecordTypes = map[string]bool{
+	"PATIENT":      true,
+	"VISIT":        true,
+	"PRESCRIPTION": true,
+	"REPORT":       true,
+	"BILLING":      true,
+	"APPOINTMENT":  true,
+}
+
+// validateRecordType returns an error unless recordType is one of the
+// known PATIENT/VISIT/PRESCRIPTION/REPORT/BILLING/APPOINTMENT types.
+func validateRecordType(recordType string) error {
+	if !validRecordTypes[recordType] {
+		return fmt.Errorf("invalid record type: %s", recordType)
+	}
+	return nil
+}
+
 // InitLedger initializes the chaincode (optional setup)
 func (c *MedicalRecordsContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	fmt.Println("Medical Records Chaincode initialized")
@@ -74,17 +157,8 @@ func (c *MedicalRecordsContract) AddRecordHash(
 	createdBy int,
 	timestamp string,
 ) error {
-	// Validate record type
-	validTypes := map[string]bool{
-		"PATIENT":      true,
-		"VISIT":        true,
-		"PRESCRIPTION": true,
-		"REPORT":       true,
-		"BILLING":      true,
-		"APPOINTMENT":  true,
-	}
-	if !validTypes[recordType] {
-		return fmt.Errorf("invalid record type: %s", recordType)
+	if err := validateRecordType(recordType); err != nil {
+		return err
 	}
 
 	// Parse hash payload
@@ -93,6 +167,13 @@ func (c *MedicalRecordsContract) AddRecordHash(
 		return fmt.Errorf("invalid hash payload JSON: %v", err)
 	}
 
+	// If the payload carries an IPFS CID, validate it and canonicalize the
+	// stored form so the same content never produces two distinct keys.
+	payload, ipfsDescriptor, err := canonicalizeIPFSPayload(payload)
+	if err != nil {
+		return err
+	}
+
 	// Get transaction ID
 	txID := ctx.GetStub().GetTxID()
 
@@ -105,6 +186,7 @@ func (c *MedicalRecordsContract) AddRecordHash(
 		CreatedBy:   createdBy,
 		Timestamp:   timestamp,
 		TxID:        txID,
+		IPFS:        ipfsDescriptor,
 	}
 
 	// Serialize and store
@@ -132,31 +214,196 @@ func (c *MedicalRecordsContract) AddRecordHash(
 		return fmt.Errorf("failed to put state with simple key: %v", err)
 	}
 
+	if err := emitRecordEvent(ctx, "ADDED", &record); err != nil {
+		return err
+	}
+
 	fmt.Printf("Record hash stored: %s (TxID: %s)\n", recordId, txID)
 	return nil
 }
 
+// UpdateRecordHash overwrites an existing record hash with a new payload,
+// preserving its RecordID/RecordType/PatientID, and emits a
+// RECORD_UPDATED.<recordType> event for subscribers.
+func (c *MedicalRecordsContract) UpdateRecordHash(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	patientId int,
+	hashPayload string,
+	recordType string,
+	createdBy int,
+	timestamp string,
+) error {
+	simpleKey := fmt.Sprintf("%s_%s", recordType, recordId)
+	existingJSON, err := ctx.GetStub().GetState(simpleKey)
+	if err != nil {
+		return fmt.Errorf("failed to read state: %v", err)
+	}
+	if existingJSON == nil {
+		return fmt.Errorf("record not found: %s", simpleKey)
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal([]byte(hashPayload), &payload); err != nil {
+		return fmt.Errorf("invalid hash payload JSON: %v", err)
+	}
+
+	// Re-validate and canonicalize any IPFS CID in the new payload so an
+	// update doesn't silently wipe the record's IPFSDescriptor.
+	payload, ipfsDescriptor, err := canonicalizeIPFSPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	txID := ctx.GetStub().GetTxID()
+
+	record := RecordHash{
+		RecordID:    recordId,
+		PatientID:   patientId,
+		HashPayload: payload,
+		RecordType:  recordType,
+		CreatedBy:   createdBy,
+		Timestamp:   timestamp,
+		TxID:        txID,
+		IPFS:        ipfsDescriptor,
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %v", err)
+	}
+
+	compositeKey, err := ctx.GetStub().CreateCompositeKey("RECORD", []string{recordType, recordId, timestamp})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(compositeKey, recordJSON); err != nil {
+		return fmt.Errorf("failed to put state: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(simpleKey, recordJSON); err != nil {
+		return fmt.Errorf("failed to put state with simple key: %v", err)
+	}
+
+	if err := emitRecordEvent(ctx, "UPDATED", &record); err != nil {
+		return err
+	}
+
+	fmt.Printf("Record hash updated: %s (TxID: %s)\n", recordId, txID)
+	return nil
+}
+
+// RevokeRecordHash marks a record as revoked by overwriting the simple key
+// with a tombstone record and emits a RECORD_REVOKED.<recordType> event.
+// The composite-key history entries are left untouched so GetRecordHistory
+// still shows the full audit trail, including the revocation.
+func (c *MedicalRecordsContract) RevokeRecordHash(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	recordType string,
+	revokedBy int,
+	timestamp string,
+) error {
+	existing, err := c.GetRecordHash(ctx, recordId, recordType)
+	if err != nil {
+		return err
+	}
+
+	txID := ctx.GetStub().GetTxID()
+
+	record := RecordHash{
+		RecordID:    recordId,
+		PatientID:   existing.PatientID,
+		HashPayload: existing.HashPayload,
+		RecordType:  recordType,
+		CreatedBy:   revokedBy,
+		Timestamp:   timestamp,
+		IPFS:        existing.IPFS,
+		TxID:        txID,
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %v", err)
+	}
+
+	compositeKey, err := ctx.GetStub().CreateCompositeKey("RECORD", []string{recordType, recordId, timestamp})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	if err := ctx.GetStub().PutState(compositeKey, recordJSON); err != nil {
+		return fmt.Errorf("failed to put state: %v", err)
+	}
+
+	simpleKey := fmt.Sprintf("%s_%s", recordType, recordId)
+	if err := ctx.GetStub().PutState(simpleKey, recordJSON); err != nil {
+		return fmt.Errorf("failed to put state with simple key: %v", err)
+	}
+
+	if err := emitRecordEvent(ctx, "REVOKED", &record); err != nil {
+		return err
+	}
+
+	fmt.Printf("Record hash revoked: %s (TxID: %s)\n", recordId, txID)
+	return nil
+}
+
 // GetRecordHash retrieves the latest hash record for a given record ID and type
 func (c *MedicalRecordsContract) GetRecordHash(
 	ctx contractapi.TransactionContextInterface,
 	recordId string,
 	recordType string,
 ) (*RecordHash, error) {
+	record, _, err := c.GetRecordHashWithProof(ctx, recordId, recordType)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetRecordHashWithProof retrieves a record the same way GetRecordHash
+// does, falling back to scanning batch commits (see AddRecordHashBatch)
+// when no individual state entry exists. When the record was derived
+// from a batch, it also returns the Merkle inclusion proof recomputed
+// against that batch's stored root; for individually-stored records the
+// proof is nil.
+func (c *MedicalRecordsContract) GetRecordHashWithProof(
+	ctx contractapi.TransactionContextInterface,
+	recordId string,
+	recordType string,
+) (*RecordHash, []MerkleProofStep, error) {
 	simpleKey := fmt.Sprintf("%s_%s", recordType, recordId)
 	recordJSON, err := ctx.GetStub().GetState(simpleKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read state: %v", err)
+		return nil, nil, fmt.Errorf("failed to read state: %v", err)
 	}
-	if recordJSON == nil {
-		return nil, fmt.Errorf("record not found: %s", simpleKey)
+
+	if recordJSON != nil {
+		var record RecordHash
+		if err := json.Unmarshal(recordJSON, &record); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal record: %v", err)
+		}
+		return &record, nil, nil
+	}
+
+	input, proof, err := findRecordInBatches(ctx, recordId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("record not found: %s", simpleKey)
 	}
 
-	var record RecordHash
-	if err := json.Unmarshal(recordJSON, &record); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal record: %v", err)
+	record := RecordHash{
+		RecordID:    input.RecordID,
+		PatientID:   input.PatientID,
+		HashPayload: input.HashPayload,
+		RecordType:  input.RecordType,
+		CreatedBy:   input.CreatedBy,
+		Timestamp:   input.Timestamp,
+		IPFS:        input.IPFS,
 	}
 
-	return &record, nil
+	return &record, proof, nil
 }
 
 // GetRecordHistory retrieves all versions of a record (for audit trail)
@@ -195,59 +442,53 @@ func (c *MedicalRecordsContract) GetRecordHistory(
 	return history, nil
 }
 
-// GetRecordsByPatient retrieves all records for a specific patient
+// GetRecordsByPatient retrieves all records for a specific patient.
+//
+// Deprecated: loads the entire result set into memory, which does not
+// scale for patients with long histories. Prefer GetRecordsByPatientPage.
 func (c *MedicalRecordsContract) GetRecordsByPatient(
 	ctx contractapi.TransactionContextInterface,
 	patientId int,
 ) ([]*RecordHash, error) {
-	// Query all record types for this patient
-	queryString := fmt.Sprintf(`{"selector":{"patientId":%d}}`, patientId)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %v", err)
-	}
-	defer resultsIterator.Close()
-
 	var records []*RecordHash
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	bookmark := ""
+
+	for {
+		page, err := c.GetRecordsByPatientPage(ctx, patientId, maxPageScanSize, bookmark, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate results: %v", err)
+			return nil, err
 		}
-
-		var record RecordHash
-		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
-			continue
+		records = append(records, page.Records...)
+		if page.NextBookmark == "" {
+			break
 		}
-		records = append(records, &record)
+		bookmark = page.NextBookmark
 	}
 
 	return records, nil
 }
 
-// GetRecordsByType retrieves all records of a specific type
+// GetRecordsByType retrieves all records of a specific type.
+//
+// Deprecated: loads the entire result set into memory, which does not
+// scale for long-lived record types. Prefer GetRecordsByTypePage.
 func (c *MedicalRecordsContract) GetRecordsByType(
 	ctx contractapi.TransactionContextInterface,
 	recordType string,
 ) ([]*RecordHash, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("RECORD", []string{recordType})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get records by type: %v", err)
-	}
-	defer resultsIterator.Close()
-
 	var records []*RecordHash
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	bookmark := ""
+
+	for {
+		page, err := c.GetRecordsByTypePage(ctx, recordType, maxPageScanSize, bookmark, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate results: %v", err)
+			return nil, err
 		}
-
-		var record RecordHash
-		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
-			continue
+		records = append(records, page.Records...)
+		if page.NextBookmark == "" {
+			break
 		}
-		records = append(records, &record)
+		bookmark = page.NextBookmark
 	}
 
 	return records, nil
@@ -0,0 +1,330 @@
+/*
+ * Hospital Management System - Medical Records Chaincode
+ * Paginated, bookmarked rich queries with server-side filtering.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxPageScanSize bounds a single pagination request issued by the
+// deprecated, non-paginated GetRecordsByPatient/GetRecordsByType wrappers
+// so they still page through CouchDB/LevelDB internally instead of
+// loading an unbounded result set in one call.
+const maxPageScanSize = 1000
+
+// RecordFilter narrows a paginated query. CreatedByIDs, TimestampFrom/To
+// and TxIDPrefix are translated into a CouchDB Mango selector when running
+// against CouchDB, and applied client-side when running against LevelDB.
+type RecordFilter struct {
+	RecordTypes   []string `json:"recordTypes,omitempty"`
+	CreatedByIDs  []int    `json:"createdByIds,omitempty"`
+	TimestampFrom string   `json:"timestampFrom,omitempty"`
+	TimestampTo   string   `json:"timestampTo,omitempty"`
+	TxIDPrefix    string   `json:"txIdPrefix,omitempty"`
+}
+
+// RecordPage is the result of a paginated record query.
+type RecordPage struct {
+	Records      []*RecordHash `json:"records"`
+	NextBookmark string        `json:"nextBookmark"`
+	FetchedCount int32         `json:"fetchedCount"`
+}
+
+// matches applies RecordFilter client-side, used both as the LevelDB path
+// and to narrow composite-key scans that CouchDB selectors can't reach
+// (e.g. TxIDPrefix against a partial composite key iterator).
+func (f *RecordFilter) matches(record *RecordHash) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.RecordTypes) > 0 && !containsString(f.RecordTypes, record.RecordType) {
+		return false
+	}
+	if len(f.CreatedByIDs) > 0 && !containsInt(f.CreatedByIDs, record.CreatedBy) {
+		return false
+	}
+	if f.TimestampFrom != "" && record.Timestamp < f.TimestampFrom {
+		return false
+	}
+	if f.TimestampTo != "" && record.Timestamp > f.TimestampTo {
+		return false
+	}
+	if f.TxIDPrefix != "" && !strings.HasPrefix(record.TxID, f.TxIDPrefix) {
+		return false
+	}
+
+	return true
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(haystack []int, needle int) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// mangoSelector translates a RecordFilter into a CouchDB Mango selector
+// fragment, to be merged with the base selector for the query.
+func (f *RecordFilter) mangoSelector() map[string]interface{} {
+	selector := map[string]interface{}{}
+	if f == nil {
+		return selector
+	}
+
+	if len(f.RecordTypes) > 0 {
+		selector["recordType"] = map[string]interface{}{"$in": f.RecordTypes}
+	}
+	if len(f.CreatedByIDs) > 0 {
+		selector["createdBy"] = map[string]interface{}{"$in": f.CreatedByIDs}
+	}
+	if f.TimestampFrom != "" || f.TimestampTo != "" {
+		timestampSelector := map[string]interface{}{}
+		if f.TimestampFrom != "" {
+			timestampSelector["$gte"] = f.TimestampFrom
+		}
+		if f.TimestampTo != "" {
+			timestampSelector["$lte"] = f.TimestampTo
+		}
+		selector["timestamp"] = timestampSelector
+	}
+	if f.TxIDPrefix != "" {
+		selector["txId"] = map[string]interface{}{"$regex": fmt.Sprintf("^%s", f.TxIDPrefix)}
+	}
+
+	return selector
+}
+
+// GetRecordsByPatientPage returns one page of records for patientId. It
+// tries the patientId+timestamp CouchDB index first so the selector (base
+// patientId match plus any RecordFilter fields) is evaluated server-side;
+// GetQueryResultWithPagination only works against a CouchDB state
+// database, so against LevelDB it falls back to a partial composite-key
+// scan across every RECORD entry, filtering patientId and RecordFilter
+// client-side.
+func (c *MedicalRecordsContract) GetRecordsByPatientPage(
+	ctx contractapi.TransactionContextInterface,
+	patientId int,
+	pageSize int32,
+	bookmark string,
+	filter *RecordFilter,
+) (*RecordPage, error) {
+	page, err := queryRecordsByPatientCouchDB(ctx, patientId, pageSize, bookmark, filter)
+	if err == nil {
+		return page, nil
+	}
+
+	return queryRecordsByPatientLevelDB(ctx, patientId, pageSize, bookmark, filter)
+}
+
+func queryRecordsByPatientCouchDB(
+	ctx contractapi.TransactionContextInterface,
+	patientId int,
+	pageSize int32,
+	bookmark string,
+	filter *RecordFilter,
+) (*RecordPage, error) {
+	selector := filter.mangoSelector()
+	selector["patientId"] = patientId
+
+	selectorJSON, err := json.Marshal(map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{"_design/patientIdTimestampIndex", "patientId-timestamp-index"},
+		"sort":      []map[string]string{{"timestamp": "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query selector: %v", err)
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(selectorJSON), pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute paginated query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// Filter client-side too: a $regex TxIDPrefix clause isn't guaranteed
+	// to be supported by every CouchDB Mango index build.
+	records, err := collectRecords(resultsIterator, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecordPage{
+		Records:      records,
+		NextBookmark: metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// queryRecordsByPatientLevelDB is the LevelDB-compatible fallback for
+// GetRecordsByPatientPage: it scans the RECORD composite-key space (there
+// is no patientId-keyed index LevelDB can use) and filters patientId and
+// RecordFilter client-side.
+func queryRecordsByPatientLevelDB(
+	ctx contractapi.TransactionContextInterface,
+	patientId int,
+	pageSize int32,
+	bookmark string,
+	filter *RecordFilter,
+) (*RecordPage, error) {
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"RECORD", []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute paginated composite-key query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var records []*RecordHash
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate paginated results: %v", err)
+		}
+
+		var record RecordHash
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+			continue
+		}
+		if record.PatientID != patientId {
+			continue
+		}
+		if filter.matches(&record) {
+			records = append(records, &record)
+		}
+	}
+
+	return &RecordPage{
+		Records:      records,
+		NextBookmark: metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// GetRecordsByTypePage returns one page of records of recordType. It
+// tries the createdBy+recordType CouchDB index first; against LevelDB,
+// GetQueryResultWithPagination fails and it falls back to
+// GetStateByPartialCompositeKeyWithPagination, with RecordFilter applied
+// client-side since composite-key range scans have no selector to push
+// filtering into.
+func (c *MedicalRecordsContract) GetRecordsByTypePage(
+	ctx contractapi.TransactionContextInterface,
+	recordType string,
+	pageSize int32,
+	bookmark string,
+	filter *RecordFilter,
+) (*RecordPage, error) {
+	page, err := queryRecordsByTypeCouchDB(ctx, recordType, pageSize, bookmark, filter)
+	if err == nil {
+		return page, nil
+	}
+
+	return queryRecordsByTypeLevelDB(ctx, recordType, pageSize, bookmark, filter)
+}
+
+func queryRecordsByTypeCouchDB(
+	ctx contractapi.TransactionContextInterface,
+	recordType string,
+	pageSize int32,
+	bookmark string,
+	filter *RecordFilter,
+) (*RecordPage, error) {
+	selector := filter.mangoSelector()
+	selector["recordType"] = recordType
+
+	selectorJSON, err := json.Marshal(map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{"_design/createdByRecordTypeIndex", "createdBy-recordType-index"},
+		"sort":      []map[string]string{{"createdBy": "asc"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query selector: %v", err)
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(selectorJSON), pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute paginated query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// Filter client-side too: a $regex TxIDPrefix clause isn't guaranteed
+	// to be supported by every CouchDB Mango index build.
+	records, err := collectRecords(resultsIterator, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecordPage{
+		Records:      records,
+		NextBookmark: metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+func queryRecordsByTypeLevelDB(
+	ctx contractapi.TransactionContextInterface,
+	recordType string,
+	pageSize int32,
+	bookmark string,
+	filter *RecordFilter,
+) (*RecordPage, error) {
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"RECORD", []string{recordType}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute paginated composite-key query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	records, err := collectRecords(resultsIterator, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecordPage{
+		Records:      records,
+		NextBookmark: metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// collectRecords drains an iterator into RecordHash values, applying
+// filter client-side when provided.
+func collectRecords(iterator shim.StateQueryIteratorInterface, filter *RecordFilter) ([]*RecordHash, error) {
+	var records []*RecordHash
+
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate paginated results: %v", err)
+		}
+
+		var record RecordHash
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil {
+			continue
+		}
+
+		if filter.matches(&record) {
+			records = append(records, &record)
+		}
+	}
+
+	return records, nil
+}
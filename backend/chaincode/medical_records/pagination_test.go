@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestRecordFilterMatches(t *testing.T) {
+	record := &RecordHash{
+		RecordType: "VISIT",
+		CreatedBy:  7,
+		Timestamp:  "2026-06-01T00:00:00Z",
+		TxID:       "tx-abc123",
+	}
+
+	cases := []struct {
+		name   string
+		filter *RecordFilter
+		want   bool
+	}{
+		{"nil filter matches everything", nil, true},
+		{"matching record type", &RecordFilter{RecordTypes: []string{"VISIT", "REPORT"}}, true},
+		{"non-matching record type", &RecordFilter{RecordTypes: []string{"REPORT"}}, false},
+		{"matching createdBy", &RecordFilter{CreatedByIDs: []int{7}}, true},
+		{"non-matching createdBy", &RecordFilter{CreatedByIDs: []int{1}}, false},
+		{"timestamp within range", &RecordFilter{TimestampFrom: "2026-01-01", TimestampTo: "2026-12-31"}, true},
+		{"timestamp before range", &RecordFilter{TimestampFrom: "2026-07-01"}, false},
+		{"matching txId prefix", &RecordFilter{TxIDPrefix: "tx-abc"}, true},
+		{"non-matching txId prefix", &RecordFilter{TxIDPrefix: "tx-xyz"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.matches(record); got != tc.want {
+				t.Fatalf("matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecordFilterMangoSelector(t *testing.T) {
+	filter := &RecordFilter{
+		RecordTypes:  []string{"VISIT"},
+		CreatedByIDs: []int{1, 2},
+	}
+
+	selector := filter.mangoSelector()
+	if _, ok := selector["recordType"]; !ok {
+		t.Fatal("expected recordType clause in Mango selector")
+	}
+	if _, ok := selector["createdBy"]; !ok {
+		t.Fatal("expected createdBy clause in Mango selector")
+	}
+
+	if got := (*RecordFilter)(nil).mangoSelector(); len(got) != 0 {
+		t.Fatalf("expected an empty selector for a nil filter, got %v", got)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Hospital Management System - Chaincode Event Listener
+ *
+ * Wraps the Fabric peer's chaincode event service so applications like
+ * audit dashboards and HL7 bridges can react to medical record writes
+ * without polling GetRecordHistory.
+ */
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// RecordEvent is the typed, deduplicated event delivered to listeners for
+// every RECORD_ADDED / RECORD_UPDATED / RECORD_REVOKED chaincode event.
+type RecordEvent struct {
+	EventName  string `json:"eventName"`
+	RecordID   string `json:"recordId"`
+	PatientID  int    `json:"patientId"`
+	RecordType string `json:"recordType"`
+	CreatedBy  int    `json:"createdBy"`
+	TxID       string `json:"txId"`
+	Timestamp  string `json:"timestamp"`
+	Hash       string `json:"hash,omitempty"`
+}
+
+// maxSeenTransactions bounds the dedup window for a Listener. Fabric only
+// ever redelivers an event shortly after a reconnect, so a Listener meant
+// to run indefinitely (e.g. an audit dashboard) doesn't need to remember
+// every TxID it has ever seen; it just needs to remember recent ones.
+const maxSeenTransactions = 10000
+
+// Listener subscribes to a chaincode's events and delivers deduplicated
+// RecordEvent values on a channel. seenTx/seenOrder together form a
+// fixed-size FIFO dedup window: oldest TxIDs are evicted once the window
+// is full, so a long-running Listener's memory stays bounded.
+type Listener struct {
+	network   *client.Network
+	seenMu    sync.Mutex
+	seenTx    map[string]bool
+	seenOrder []string
+}
+
+// NewListener wraps the given Fabric Gateway network connection so its
+// chaincode events can be consumed as typed RecordEvent structs.
+func NewListener(network *client.Network) *Listener {
+	return &Listener{
+		network: network,
+		seenTx:  make(map[string]bool),
+	}
+}
+
+// RegisterEventListener starts listening for chaincode events from the
+// given chaincode name and returns a channel of typed RecordEvent structs.
+// Events carrying a TxID already delivered on this Listener are dropped,
+// since Fabric may redeliver events on reconnect.
+func (l *Listener) RegisterEventListener(ctx context.Context, chaincodeName string) (<-chan *RecordEvent, error) {
+	events, err := l.network.ChaincodeEvents(ctx, chaincodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to chaincode events: %v", err)
+	}
+
+	out := make(chan *RecordEvent)
+
+	go func() {
+		defer close(out)
+		for event := range events {
+			var recordEvent RecordEvent
+			if err := json.Unmarshal(event.Payload, &recordEvent); err != nil {
+				continue
+			}
+			recordEvent.EventName = event.EventName
+
+			if l.markSeen(event.TransactionID) {
+				continue
+			}
+
+			select {
+			case out <- &recordEvent:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// markSeen returns true if the TxID has already been delivered, and
+// records it as seen otherwise, evicting the oldest entry once the
+// dedup window exceeds maxSeenTransactions.
+func (l *Listener) markSeen(txID string) bool {
+	l.seenMu.Lock()
+	defer l.seenMu.Unlock()
+
+	if l.seenTx[txID] {
+		return true
+	}
+
+	l.seenTx[txID] = true
+	l.seenOrder = append(l.seenOrder, txID)
+
+	if len(l.seenOrder) > maxSeenTransactions {
+		oldest := l.seenOrder[0]
+		l.seenOrder = l.seenOrder[1:]
+		delete(l.seenTx, oldest)
+	}
+
+	return false
+}
@@ -0,0 +1,46 @@
+package events
+
+import "testing"
+
+func TestMarkSeenDeduplicates(t *testing.T) {
+	l := NewListener(nil)
+
+	if l.markSeen("tx-1") {
+		t.Fatal("expected the first sighting of tx-1 to not be a duplicate")
+	}
+	if !l.markSeen("tx-1") {
+		t.Fatal("expected the second sighting of tx-1 to be a duplicate")
+	}
+}
+
+func TestMarkSeenEvictsOldestBeyondWindow(t *testing.T) {
+	l := NewListener(nil)
+
+	for i := 0; i < maxSeenTransactions; i++ {
+		l.markSeen(txID(i))
+	}
+	if len(l.seenTx) != maxSeenTransactions {
+		t.Fatalf("expected %d tracked transactions, got %d", maxSeenTransactions, len(l.seenTx))
+	}
+
+	// One more pushes the window past its bound; the oldest entry should
+	// be evicted so memory stays bounded for a long-running Listener.
+	l.markSeen(txID(maxSeenTransactions))
+	if len(l.seenTx) != maxSeenTransactions {
+		t.Fatalf("expected dedup window to stay bounded at %d, got %d", maxSeenTransactions, len(l.seenTx))
+	}
+	if l.seenTx[txID(0)] {
+		t.Fatal("expected the oldest transaction to have been evicted")
+	}
+	if l.markSeen(txID(0)) {
+		t.Fatal("expected the evicted transaction to no longer be treated as a duplicate")
+	}
+}
+
+// txID produces up to 16^4 = 65536 distinct 4-hex-digit IDs, comfortably
+// more than maxSeenTransactions so the eviction test below never wraps
+// around and collides with an ID it already used.
+func txID(i int) string {
+	const hex = "0123456789abcdef"
+	return string(hex[i%16]) + string(hex[(i/16)%16]) + string(hex[(i/256)%16]) + string(hex[(i/4096)%16])
+}